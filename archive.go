@@ -0,0 +1,295 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// archive format identifiers accepted via the "archive" query parameter or
+// a trailing pseudo-extension such as "/path/.zip".
+const (
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+
+	maxArchiveSize    = 512 << 20 // 512 MiB of uncompressed content
+	maxArchiveEntries = 10000
+)
+
+// archiveFormat inspects the request for an explicit archive format, via
+// either "?archive=zip"/"?archive=tar.gz" against the current path, or a
+// "/.zip"/"/.tar.gz" pseudo-extension appended to the directory's path. It
+// returns "" and r.URL.Path unchanged if no archive was requested; otherwise
+// dirPath is r.URL.Path with the pseudo-extension removed, so the caller can
+// resolve the directory it actually names.
+func archiveFormat(r *http.Request) (format, dirPath string) {
+	dirPath = r.URL.Path
+	switch f := r.URL.Query().Get("archive"); f {
+	case archiveZip, archiveTarGz:
+		return f, dirPath
+	}
+	// Behind http.StripPrefix("/", ...) the root directory's pseudo-extension
+	// arrives without its leading slash.
+	switch dirPath {
+	case ".zip":
+		return archiveZip, ""
+	case ".tar.gz":
+		return archiveTarGz, ""
+	}
+	if rest := strings.TrimSuffix(dirPath, "/.zip"); rest != dirPath {
+		return archiveZip, rest
+	}
+	if rest := strings.TrimSuffix(dirPath, "/.tar.gz"); rest != dirPath {
+		return archiveTarGz, rest
+	}
+	return "", dirPath
+}
+
+// handleArchive streams the contents of the directory name (relative to
+// store) to w as a compressed archive in the given format. It walks the
+// tree rooted at name, applying the same dotfile policy used for directory
+// listings (.browseignore patterns are not consulted), and writes directly
+// to w without staging a temporary file on disk.
+func handleArchive(w http.ResponseWriter, r *http.Request, name, format string) {
+	size, entries, err := archiveStats(name)
+	if err != nil {
+		log.Println(err.Error())
+		renderError(w, 500)
+		return
+	}
+	if size > maxArchiveSize || entries > maxArchiveEntries {
+		http.Error(w, "directory too large to archive", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	base := path.Base(name)
+	if base == "." || base == "/" {
+		base = path.Base(Root)
+	}
+	if base == "." || base == "/" || base == "" {
+		base = "root"
+	}
+
+	// The server's WriteTimeout is sized for ordinary responses, not the
+	// archives maxArchiveSize permits; archiveStats above already bounds
+	// how large (and how costly) this response can be, so there's no
+	// unbounded-write risk in exempting it from that deadline.
+	disableWriteDeadline(w)
+
+	switch format {
+	case archiveZip:
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, base))
+		writeZip(w, name)
+	case archiveTarGz:
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.tar.gz"`, base))
+		writeTarGz(w, name)
+	default:
+		renderError(w, 400)
+	}
+}
+
+// disableWriteDeadline clears the response's write deadline (set from the
+// server's WriteTimeout), best-effort. It's a no-op if the underlying
+// ResponseWriter doesn't support deadlines (as in tests using
+// httptest.ResponseRecorder).
+func disableWriteDeadline(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Println(err.Error())
+	}
+}
+
+// archiveStats walks name and reports the total uncompressed size and entry
+// count that an archive of it would contain, so handleArchive can refuse
+// oversized requests before it starts streaming a response.
+func archiveStats(name string) (size int64, entries int, err error) {
+	err = fs.WalkDir(store, name, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if resErr := store.Resolve(p); resErr != nil {
+			log.Println(p, resErr.Error())
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if p != name && !policy.ShowHidden && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		entries++
+		if !d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, entries, err
+}
+
+// writeZip streams name (a directory) as a zip archive to w.
+func writeZip(w http.ResponseWriter, name string) {
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	fs.WalkDir(store, name, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if resErr := store.Resolve(p); resErr != nil {
+			log.Println(p, resErr.Error())
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := relArchivePath(name, p)
+		if err != nil || rel == "" {
+			return err
+		}
+		if !policy.ShowHidden && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if _, err := zw.Create(rel + "/"); err != nil {
+				log.Println(p, err.Error())
+			}
+			return nil
+		}
+
+		// A single bad entry (e.g. a symlink to a directory, which Open
+		// follows but reads as "is a directory") must not abort the whole
+		// walk: the archive's headers are already flushed to w by this
+		// point, so failing the request here would just produce a silently
+		// truncated download. Log and skip it instead.
+		info, err := d.Info()
+		if err != nil {
+			log.Println(p, err.Error())
+			return nil
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			log.Println(p, err.Error())
+			return nil
+		}
+		header.Name = rel
+		header.Method = zip.Deflate
+
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			log.Println(p, err.Error())
+			return nil
+		}
+
+		f, err := store.Open(p)
+		if err != nil {
+			log.Println(p, err.Error())
+			return nil
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(entry, f); err != nil {
+			log.Println(p, err.Error())
+		}
+		return nil
+	})
+}
+
+// writeTarGz streams name (a directory) as a gzip-compressed tar archive to w.
+func writeTarGz(w http.ResponseWriter, name string) {
+	gw := gzip.NewWriter(w)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	fs.WalkDir(store, name, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if resErr := store.Resolve(p); resErr != nil {
+			log.Println(p, resErr.Error())
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := relArchivePath(name, p)
+		if err != nil || rel == "" {
+			return err
+		}
+		if !policy.ShowHidden && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// See writeZip: one bad entry must not abort the whole walk, since
+		// headers and earlier entries are already flushed to w.
+		info, err := d.Info()
+		if err != nil {
+			log.Println(p, err.Error())
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			log.Println(p, err.Error())
+			return nil
+		}
+		header.Name = rel
+		if d.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			log.Println(p, err.Error())
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := store.Open(p)
+		if err != nil {
+			log.Println(p, err.Error())
+			return nil
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(tw, f); err != nil {
+			log.Println(p, err.Error())
+		}
+		return nil
+	})
+}
+
+// relArchivePath returns p relative to root ("" if p == root), the name
+// each archive entry is stored under.
+func relArchivePath(root, p string) (string, error) {
+	if p == root {
+		return "", nil
+	}
+	if root == "." {
+		return p, nil
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p, root), "/"), nil
+}
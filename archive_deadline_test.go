@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// deadlineRecorder is an httptest.ResponseRecorder that also implements the
+// SetWriteDeadline method http.ResponseController looks for, so
+// disableWriteDeadline has something to observe.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadline time.Time
+	set      bool
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	d.deadline = t
+	d.set = true
+	return nil
+}
+
+// TestDisableWriteDeadlineClearsDeadline verifies that disableWriteDeadline
+// resets the response's write deadline to the zero value (no deadline),
+// so a large archive download isn't cut off by the server's WriteTimeout.
+func TestDisableWriteDeadlineClearsDeadline(t *testing.T) {
+	w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	disableWriteDeadline(w)
+
+	if !w.set {
+		t.Fatal("disableWriteDeadline never called SetWriteDeadline")
+	}
+	if !w.deadline.IsZero() {
+		t.Fatalf("deadline = %v, want zero value", w.deadline)
+	}
+}
+
+// TestDisableWriteDeadlineIgnoresUnsupported verifies that
+// disableWriteDeadline is a silent no-op against a ResponseWriter that
+// doesn't support write deadlines, such as a plain
+// httptest.ResponseRecorder.
+func TestDisableWriteDeadlineIgnoresUnsupported(t *testing.T) {
+	disableWriteDeadline(httptest.NewRecorder())
+}
+
+var _ http.ResponseWriter = (*deadlineRecorder)(nil)
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteZipExcludesSymlinkEscapes verifies that a symlink pointing
+// outside the store root is excluded from archive downloads, not just
+// direct requests, per the containment policy dirStore.Resolve enforces.
+func TestWriteZipExcludesSymlinkEscapes(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret-outside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "real.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "evil-link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	origStore, origPolicy := store, policy
+	defer func() { store, policy = origStore, origPolicy }()
+	var err error
+	store, err = newDirStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy = Policy{FollowSymlinks: false, ShowHidden: false}
+
+	w := httptest.NewRecorder()
+	writeZip(w, ".")
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+
+	sawReal := false
+	for _, f := range zr.File {
+		if f.Name == "evil-link.txt" {
+			t.Fatalf("archive included symlink escaping store root: %s", f.Name)
+		}
+		if f.Name == "real.txt" {
+			sawReal = true
+		}
+	}
+	if !sawReal {
+		t.Fatal("archive is missing real.txt, the one legitimate entry")
+	}
+}
+
+// TestWriteZipSkipsUnreadableEntryInsteadOfAborting verifies that a single
+// entry that fails to open or read (here, a symlink to a directory, which
+// Open follows but fails to read from) doesn't abort the whole walk: every
+// other entry must still make it into the archive.
+func TestWriteZipSkipsUnreadableEntryInsteadOfAborting(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "subdir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "subdir"), filepath.Join(root, "dir-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	origStore, origPolicy := store, policy
+	defer func() { store, policy = origStore, origPolicy }()
+	var err error
+	store, err = newDirStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy = Policy{FollowSymlinks: true, ShowHidden: false}
+
+	w := httptest.NewRecorder()
+	writeZip(w, ".")
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, f := range zr.File {
+		seen[f.Name] = true
+	}
+	if !seen["a.txt"] || !seen["b.txt"] {
+		t.Fatalf("archive dropped entries after the unreadable one: %v", seen)
+	}
+}
+
+// TestArchiveFormatSuffix verifies that the "/.zip"/"/.tar.gz" pseudo-
+// extension trigger resolves to the directory it names, with the
+// extension removed.
+func TestArchiveFormatSuffix(t *testing.T) {
+	cases := []struct {
+		path       string
+		wantFormat string
+		wantDir    string
+	}{
+		{"/sub/.zip", archiveZip, "/sub"},
+		{"/sub/.tar.gz", archiveTarGz, "/sub"},
+		{"/.zip", archiveZip, ""},
+		{"/sub/file.txt", "", "/sub/file.txt"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		format, dir := archiveFormat(r)
+		if format != c.wantFormat || dir != c.wantDir {
+			t.Errorf("archiveFormat(%q) = (%q, %q), want (%q, %q)", c.path, format, dir, c.wantFormat, c.wantDir)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Crumb is a single segment of a breadcrumb trail, linking back to one of
+// the ancestors of the directory currently being listed.
+type Crumb struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// breadcrumbs builds the chain of Crumbs from the store root down to name,
+// so the template can render a clickable path for the current listing.
+func breadcrumbs(name string) []Crumb {
+	rel := abs(name)
+	if rel == "/" {
+		return []Crumb{{Name: "/", Path: "/"}}
+	}
+
+	parts := strings.Split(strings.Trim(rel, "/"), "/")
+	crumbs := make([]Crumb, 0, len(parts)+1)
+	crumbs = append(crumbs, Crumb{Name: "/", Path: "/"})
+
+	path := ""
+	for _, part := range parts {
+		path = filepath.Clean("/" + path + "/" + part)
+		crumbs = append(crumbs, Crumb{Name: part, Path: path})
+	}
+	return crumbs
+}
+
+// sortName/sortSize/sortTime and orderAsc/orderDesc are the recognized
+// values of the "sort" and "order" query parameters accepted by
+// handleDirectory.
+const (
+	sortName = "name"
+	sortSize = "size"
+	sortTime = "time"
+
+	orderAsc  = "asc"
+	orderDesc = "desc"
+)
+
+// sortFiles orders files in place by field ("name", "size", or "time"),
+// applying order ("asc" or "desc"); unrecognized values fall back to
+// sortName/orderAsc.
+func sortFiles(files []os.FileInfo, field, order string) {
+	less := func(i, j int) bool {
+		a, b := files[i], files[j]
+		switch field {
+		case sortSize:
+			return a.Size() < b.Size()
+		case sortTime:
+			return a.ModTime().Before(b.ModTime())
+		default:
+			return a.Name() < b.Name()
+		}
+	}
+	if order == orderDesc {
+		sort.SliceStable(files, func(i, j int) bool { return less(j, i) })
+		return
+	}
+	sort.SliceStable(files, less)
+}
+
+// humansize formats n bytes as a short human-readable string (e.g. "4.2
+// MiB"), following the same base-1024 convention as dustin/go-humanize.
+func humansize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// humantime formats t in the format used throughout directory listings.
+func humantime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -1,76 +1,68 @@
 package main
 
 import (
-	"fmt"
+	"encoding/json"
 	"html/template"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
-)
-
-var (
-	Root string
-
-	// Template is an HTML template for a directory listing page/index.html
-	Template = template.Must(template.New("index.html").Funcs(template.FuncMap{
-		"abs":   abs,
-		"clean": filepath.Clean,
-	}).Parse(`
-<!DOCTYPE html>
-<html>
-	<head>
-		<title>Index &middot; {{ abs .Dir .Root }}</title>
-	</head>
-	<body>
-		{{ $dir := abs .Dir .Root }}
-		<ul>
-			{{ range .Directories }}
-			<li>
-				<a href="{{printf "%s/%s" $dir .Name | clean}}">{{.Name}}</a>
-			</li>
-			{{ end }}
-			{{ range .Files }}
-			<li>
-				<a href="{{printf "%s/%s" $dir .Name | clean}}">{{.Name}}</a>
-			</li>
-			{{ end }}
-		</ul>
-		<style>
-		ul,ul li{padding:0}a,a:visited{color:#00f}:root{font-size:100%}
-		body{font-family:monospace;font-size:1rem}
-		ul{list-style-type:none;margin:0}ul li{margin:1em}a{text-decoration:none}
-		</style>
-	</body>
-</html>
-`))
+	"time"
 )
 
 // Listing is a list of files and directories under a path.
 type Listing struct {
 	Root        string        `json:"root,omitempty"`
 	Dir         string        `json:"dir,omitempty"`
-	Directories []os.FileInfo `json:"directories,omitempty"`
-	Files       []os.FileInfo `json:"files,omitempty"`
+	Directories []os.FileInfo `json:"-"`
+	Files       []os.FileInfo `json:"-"`
+	Breadcrumbs []Crumb       `json:"breadcrumbs,omitempty"`
+	Sort        string        `json:"sort,omitempty"`
+	Order       string        `json:"order,omitempty"`
+	Readme      template.HTML `json:"readme,omitempty"`
 }
 
-func main() {
-	var err error
-
-	Root, err := os.Getwd()
-	if err != nil {
-		log.Fatal(err)
-	}
+// MarshalJSON implements json.Marshaler. os.FileInfo's concrete
+// implementations have no exported fields, so Directories/Files are
+// re-encoded as FileEntry before marshaling; the template still reads the
+// os.FileInfo fields directly via Listing.Directories/Listing.Files.
+func (l Listing) MarshalJSON() ([]byte, error) {
+	type alias Listing
+	return json.Marshal(struct {
+		alias
+		Directories []FileEntry `json:"directories,omitempty"`
+		Files       []FileEntry `json:"files,omitempty"`
+	}{
+		alias:       alias(l),
+		Directories: fileEntries(l.Directories),
+		Files:       fileEntries(l.Files),
+	})
+}
 
-	mux := http.NewServeMux()
-	fileServer := http.FileServer(http.Dir(Root))
-	mux.Handle("/", http.StripPrefix("/", loggingMiddleware(handleServe(fileServer))))
+// FileEntry is the JSON-serializable view of an os.FileInfo exposed on a
+// Listing.
+type FileEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	IsDir   bool      `json:"is_dir"`
+}
 
-	fmt.Printf("Serving at address: http://localhost:3000\n")
-	err = http.ListenAndServe(":3000", mux)
-	log.Fatal(err)
+// fileEntries converts a slice of os.FileInfo into their JSON-serializable
+// FileEntry form.
+func fileEntries(files []os.FileInfo) []FileEntry {
+	entries := make([]FileEntry, 0, len(files))
+	for _, fi := range files {
+		entries = append(entries, FileEntry{
+			Name:    fi.Name(),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			IsDir:   fi.IsDir(),
+		})
+	}
+	return entries
 }
 
 func filter(files []os.FileInfo, f func(os.FileInfo) bool) []os.FileInfo {
@@ -83,61 +75,122 @@ func filter(files []os.FileInfo, f func(os.FileInfo) bool) []os.FileInfo {
 	return vsf
 }
 
-func filterFiles(files []os.FileInfo) []os.FileInfo {
-	// filter out hidden files (.DS_Store, configs, etc.)
-	files = filter(files, func(fi os.FileInfo) bool {
-		return !strings.HasPrefix(fi.Name(), ".")
+// filterFiles applies the dotfile and .browseignore policy for the
+// directory name to files.
+func filterFiles(name string, files []os.FileInfo) []os.FileInfo {
+	ignore := loadBrowseIgnore(name)
+	return filter(files, func(fi os.FileInfo) bool {
+		if !policy.ShowHidden && strings.HasPrefix(fi.Name(), ".") {
+			return false
+		}
+		for _, pattern := range ignore {
+			if ok, _ := path.Match(pattern, fi.Name()); ok {
+				return false
+			}
+		}
+		return true
 	})
-	return files
 }
 
-func abs(dir, root string) string {
-	dir, _ = filepath.Abs(dir)
-	root, _ = filepath.Abs(root)
-	path := strings.TrimPrefix(dir, root)
-	return fmt.Sprintf(filepath.Clean("/" + path))
+// abs converts a store-relative name ("." for the store root, "foo/bar"
+// otherwise) into the absolute URL path clients should request.
+func abs(name string) string {
+	if name == "." || name == "" {
+		return "/"
+	}
+	return filepath.Clean("/" + name)
 }
 
-func handleDirectory(w http.ResponseWriter, r *http.Request, path string) {
-	contents, err := ioutil.ReadDir(path)
+func handleDirectory(w http.ResponseWriter, r *http.Request, name string) {
+	contents, err := store.ReadDir(name)
 	if err != nil {
-		http.Error(w, http.StatusText(500), 500)
+		renderError(w, 500)
 		return
 	}
-	contents = filterFiles(contents)
+	contents = filterFiles(name, contents)
+
+	query := r.URL.Query()
+	sortField, order := query.Get("sort"), query.Get("order")
+	if sortField == "" {
+		sortField = sortName
+	}
+	if order == "" {
+		order = orderAsc
+	}
+	sortFiles(contents, sortField, order)
 
 	directories, files := splitFiles(contents, func(fi os.FileInfo) bool {
 		return fi.IsDir()
 	})
 	list := Listing{
 		Root:        Root,
-		Dir:         path,
+		Dir:         name,
 		Directories: directories,
 		Files:       files,
+		Breadcrumbs: breadcrumbs(name),
+		Sort:        sortField,
+		Order:       order,
+		Readme:      readReadme(name, files),
 	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			log.Println(err.Error())
+			renderError(w, 500)
+		}
+		return
+	}
+
 	if err := Template.ExecuteTemplate(w, "index.html", list); err != nil {
 		log.Println(err.Error())
-		http.Error(w, http.StatusText(500), 500)
+		renderError(w, 500)
 	}
 }
 
+// wantsJSON reports whether the request asked for a JSON directory listing,
+// either via "?format=json" or an "Accept: application/json" header.
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
 func handleServe(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := filepath.Clean(r.URL.Path)
+		format, dirPath := archiveFormat(r)
+		name := storePath(filepath.Clean(dirPath))
+
+		if err := store.Resolve(name); err != nil {
+			renderError(w, http.StatusForbidden)
+			return
+		}
 
-		info, err := os.Stat(path)
+		info, err := store.Stat(name)
 		if err != nil {
 			if os.IsNotExist(err) {
-				http.NotFound(w, r)
+				renderError(w, http.StatusNotFound)
 				return
 			}
+			log.Println(err.Error())
+			renderError(w, 500)
+			return
 		}
 
 		// if we're serving a directory (index.html) short-circuit and return
-		// a custom page
+		// a custom page, or a streamed archive if one was requested
 		if info.IsDir() {
-			ioutil.ReadDir(path)
-			handleDirectory(w, r, path)
+			if format != "" {
+				handleArchive(w, r, name, format)
+				return
+			}
+			handleDirectory(w, r, name)
+			return
+		}
+
+		if path.Ext(name) == ".md" {
+			handleMarkdown(w, r, name, next)
 			return
 		}
 
@@ -147,7 +200,7 @@ func handleServe(next http.Handler) http.Handler {
 
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Println(abs(filepath.Clean(r.URL.Path), Root), r.RemoteAddr)
+		log.Println(abs(storePath(filepath.Clean(r.URL.Path))), r.RemoteAddr)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestListingMarshalJSON verifies that Directories/Files survive JSON
+// encoding as usable data, not the empty objects a bare []os.FileInfo
+// would produce.
+func TestListingMarshalJSON(t *testing.T) {
+	list := Listing{
+		Dir:   ".",
+		Files: []os.FileInfo{fakeFileInfo{name: "notes.txt", size: 42, modTime: time.Unix(0, 0)}},
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var decoded struct {
+		Files []FileEntry `json:"files"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(decoded.Files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(decoded.Files))
+	}
+	if got := decoded.Files[0]; got.Name != "notes.txt" || got.Size != 42 {
+		t.Fatalf("unexpected FileEntry: %+v", got)
+	}
+}
+
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return f.size }
+func (f fakeFileInfo) Mode() os.FileMode  { return 0o644 }
+func (f fakeFileInfo) ModTime() time.Time { return f.modTime }
+func (f fakeFileInfo) IsDir() bool        { return false }
+func (f fakeFileInfo) Sys() interface{}   { return nil }
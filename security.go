@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// Policy holds the containment and visibility settings handleServe and
+// filterFiles enforce. It is populated from flags in main.
+type Policy struct {
+	// FollowSymlinks allows requests to resolve through symlinks that
+	// point outside Store's root. Off by default.
+	FollowSymlinks bool
+	// ShowHidden includes dotfiles in directory listings and archives.
+	// Off by default.
+	ShowHidden bool
+}
+
+// policy is the active Policy, set from flags in main before the server
+// starts serving requests.
+var policy = Policy{
+	FollowSymlinks: false,
+	ShowHidden:     false,
+}
+
+// errSymlinkEscape is returned by Store.Resolve when a request path
+// resolves, via a symlink, to somewhere outside the store root.
+var errSymlinkEscape = errors.New("path escapes store root via symlink")
+
+// browseIgnoreFile is the per-directory glob file filterFiles consults, in
+// the same spirit as .gitignore: one shell glob pattern (path.Match syntax)
+// per line, matched against file basenames in that directory.
+const browseIgnoreFile = ".browseignore"
+
+// loadBrowseIgnore reads the .browseignore file in the directory name, if
+// present, and returns its glob patterns. A missing or unreadable file is
+// treated as "no patterns" rather than an error.
+func loadBrowseIgnore(name string) []string {
+	f, err := store.Open(path.Join(name, browseIgnoreFile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
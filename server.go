@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var (
+	// Root is the path the server was started against, as given on the
+	// command line (or the working directory if none was given). It is
+	// used only for display; all reads go through store.
+	Root string
+
+	// store is the backend all directory/file reads go through, selected
+	// by newStore based on Root's suffix.
+	store Store
+)
+
+func main() {
+	var (
+		addr           = flag.String("addr", ":3000", "address to listen on")
+		root           = flag.String("root", "", "directory (or .zip archive) to serve; defaults to the working directory")
+		tlsCert        = flag.String("tls-cert", "", "TLS certificate file; enables HTTPS when set along with -tls-key")
+		tlsKey         = flag.String("tls-key", "", "TLS key file")
+		readTimeout    = flag.Duration("read-timeout", 10*time.Second, "maximum duration for reading the entire request")
+		writeTimeout   = flag.Duration("write-timeout", 30*time.Second, "maximum duration before timing out writes of the response (archive downloads are exempt; see maxArchiveSize)")
+		followSymlinks = flag.Bool("follow-symlinks", false, "allow serving through symlinks that point outside root")
+		showHidden     = flag.Bool("show-hidden", false, "include dotfiles in directory listings and archives")
+		templatesDir   = flag.String("templates", "", "directory with index.html/error.html overrides for the built-in templates")
+		staticDir      = flag.String("static", "", "directory served at /_browse/static/ for custom CSS/JS; disabled if unset")
+	)
+	flag.Parse()
+
+	policy.FollowSymlinks = *followSymlinks
+	policy.ShowHidden = *showHidden
+
+	if err := loadTemplates(*templatesDir); err != nil {
+		log.Fatal(err)
+	}
+
+	Root = *root
+	if Root == "" {
+		if args := flag.Args(); len(args) > 0 {
+			Root = args[0]
+		} else {
+			wd, err := os.Getwd()
+			if err != nil {
+				log.Fatal(err)
+			}
+			Root = wd
+		}
+	}
+
+	var err error
+	store, err = newStore(Root)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	fileServer := http.FileServer(http.FS(store))
+	mux.Handle("/", http.StripPrefix("/", loggingMiddleware(handleServe(fileServer))))
+	if *staticDir != "" {
+		mux.Handle("/_browse/static/", http.StripPrefix("/_browse/static/", http.FileServer(http.Dir(*staticDir))))
+	}
+
+	srv := &http.Server{
+		Addr:         *addr,
+		Handler:      mux,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if *tlsCert != "" && *tlsKey != "" {
+			fmt.Printf("Serving %s at address: https://localhost%s\n", Root, *addr)
+			serveErr <- srv.ListenAndServeTLS(*tlsCert, *tlsKey)
+			return
+		}
+		fmt.Printf("Serving %s at address: http://localhost%s\n", Root, *addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	case <-ctx.Done():
+		stop()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Store is the backend a browse server reads from. It mirrors io/fs.FS's
+// Open method so any fs.FS can back it, plus the Stat/ReadDir operations
+// handleServe and handleDirectory need, so they never have to reach for
+// os.Stat or ioutil.ReadDir directly.
+type Store interface {
+	fs.FS
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.FileInfo, error)
+	// Resolve checks name against the store's containment policy,
+	// returning errSymlinkEscape if serving it would escape the store's
+	// root (e.g. by following a symlink) and that isn't permitted by
+	// policy.FollowSymlinks.
+	Resolve(name string) error
+}
+
+// fsStore adapts any fs.FS into a Store, using the fs package's generic
+// Stat/ReadDir helpers (which take advantage of fs.StatFS/fs.ReadDirFS
+// when the underlying filesystem implements them).
+type fsStore struct {
+	fsys fs.FS
+}
+
+func (s fsStore) Open(name string) (fs.File, error) { return s.fsys.Open(name) }
+
+func (s fsStore) Stat(name string) (fs.FileInfo, error) { return fs.Stat(s.fsys, name) }
+
+func (s fsStore) ReadDir(name string) ([]fs.FileInfo, error) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Resolve is a no-op for fsStore: archive- and embed-backed filesystems
+// have no symlinks that can point outside themselves.
+func (s fsStore) Resolve(name string) error { return nil }
+
+// dirStore is a Store backed by a real directory on disk. Unlike fsStore,
+// it retains root (always absolute, so the path Resolve passes to
+// filepath.EvalSymlinks, and therefore its cleaned result, is absolute too
+// and can be compared against root) so Resolve can detect symlinks that
+// escape it.
+type dirStore struct {
+	fsStore
+	root string
+}
+
+// Resolve rejects names that, once symlinks are followed, fall outside
+// root, unless policy.FollowSymlinks permits it.
+func (s dirStore) Resolve(name string) error {
+	if policy.FollowSymlinks {
+		return nil
+	}
+
+	full := filepath.Join(s.root, name)
+	resolved, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		// Let Stat/Open report a missing or unreadable path themselves.
+		return nil
+	}
+
+	if resolved != s.root && !strings.HasPrefix(resolved, s.root+string(filepath.Separator)) {
+		return errSymlinkEscape
+	}
+	return nil
+}
+
+// newDirStore returns a Store backed by the local directory at root, which
+// may be relative or absolute.
+func newDirStore(root string) (Store, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return dirStore{fsStore: fsStore{fsys: os.DirFS(root)}, root: absRoot}, nil
+}
+
+// newZipStore returns a Store backed by the contents of the .zip archive
+// at path, opened once and served read-only.
+func newZipStore(path string) (Store, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return fsStore{fsys: zr}, nil
+}
+
+// newEmbedStore returns a Store backed by an embed.FS, for single-binary
+// deployments that ship their tree baked into the executable.
+func newEmbedStore(fsys fs.FS) Store {
+	return fsStore{fsys: fsys}
+}
+
+// newStore picks a Store implementation for root based on its suffix: a
+// ".zip" root is opened as a zip archive, anything else is treated as a
+// local directory.
+func newStore(root string) (Store, error) {
+	if strings.HasSuffix(root, ".zip") {
+		return newZipStore(root)
+	}
+	return newDirStore(root)
+}
+
+// storePath converts a request path (e.g. "/foo/../bar") into the
+// relative, slash-trimmed, traversal-free name fs.FS implementations
+// expect ("bar", or "." for the store root).
+func storePath(p string) string {
+	name := path.Clean(strings.TrimPrefix(p, "/"))
+	if name == "." || name == "" || name == ".." || strings.HasPrefix(name, "../") {
+		return "."
+	}
+	return name
+}
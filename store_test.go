@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDirStoreResolveAcceptsRelativeRoot verifies that a relative root
+// (e.g. "." or "./public", as given on the command line) doesn't make
+// Resolve reject every request: filepath.EvalSymlinks returns a cleaned
+// path ("file.txt", not "./file.txt"), so root must be made absolute for
+// the joined path and its resolution to keep root as a prefix.
+func TestDirStoreResolveAcceptsRelativeRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newDirStore(".")
+	if err != nil {
+		t.Fatalf("newDirStore: %v", err)
+	}
+
+	origPolicy := policy
+	defer func() { policy = origPolicy }()
+	policy = Policy{FollowSymlinks: false, ShowHidden: false}
+
+	if err := s.Resolve("file.txt"); err != nil {
+		t.Fatalf("Resolve(%q) against relative root: %v", "file.txt", err)
+	}
+}
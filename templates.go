@@ -0,0 +1,101 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+//go:embed templates/index.html templates/error.html
+var defaultTemplates embed.FS
+
+// templateFuncs are exposed to both the index and error templates, and to
+// any override a user supplies via -templates.
+var templateFuncs = template.FuncMap{
+	"abs":       abs,
+	"clean":     filepath.Clean,
+	"humansize": humansize,
+	"humantime": humantime,
+	"join":      path.Join,
+}
+
+var (
+	// Template renders a directory listing. It defaults to the embedded
+	// templates/index.html and can be overridden by -templates.
+	Template *template.Template
+
+	// ErrorTemplate renders an ErrorPage. It defaults to the embedded
+	// templates/error.html and can be overridden by -templates.
+	ErrorTemplate *template.Template
+)
+
+func init() {
+	var err error
+	Template, err = template.New("index.html").Funcs(templateFuncs).ParseFS(defaultTemplates, "templates/index.html")
+	if err != nil {
+		panic(err)
+	}
+	ErrorTemplate, err = template.New("error.html").Funcs(templateFuncs).ParseFS(defaultTemplates, "templates/error.html")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// loadTemplates overrides Template and/or ErrorTemplate with index.html
+// and error.html found in dir, leaving the embedded defaults in place for
+// whichever file isn't present. Any other *.html files in dir (e.g. a
+// shared header/footer) are parsed into both template sets as partials, so
+// index.html/error.html can {{template "..."}} into them. dir == "" is a
+// no-op.
+func loadTemplates(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	partials, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return err
+	}
+
+	if idxPath := filepath.Join(dir, "index.html"); fileExists(idxPath) {
+		tmpl, err := template.New("index.html").Funcs(templateFuncs).ParseFiles(partials...)
+		if err != nil {
+			return err
+		}
+		Template = tmpl
+	}
+
+	if errPath := filepath.Join(dir, "error.html"); fileExists(errPath) {
+		tmpl, err := template.New("error.html").Funcs(templateFuncs).ParseFiles(partials...)
+		if err != nil {
+			return err
+		}
+		ErrorTemplate = tmpl
+	}
+
+	return nil
+}
+
+func fileExists(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && !info.IsDir()
+}
+
+// ErrorPage is the data ErrorTemplate renders.
+type ErrorPage struct {
+	Status  int
+	Message string
+}
+
+// renderError writes status to w, rendered through ErrorTemplate with a
+// plain http.Error fallback if that fails.
+func renderError(w http.ResponseWriter, status int) {
+	w.WriteHeader(status)
+	page := ErrorPage{Status: status, Message: http.StatusText(status)}
+	if err := ErrorTemplate.Execute(w, page); err != nil {
+		http.Error(w, page.Message, status)
+	}
+}
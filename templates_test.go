@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadTemplatesIncludesPartials verifies that an override directory's
+// other *.html files are parsed alongside index.html/error.html, so a
+// template can {{template "footer.html"}} into them.
+func TestLoadTemplatesIncludesPartials(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("footer.html", `{{define "footer.html"}}footer-ok{{end}}`)
+	write("index.html", `{{define "index.html"}}index-{{template "footer.html"}}{{end}}`)
+	write("error.html", `{{define "error.html"}}error-{{template "footer.html"}}{{end}}`)
+
+	origTemplate, origErrorTemplate := Template, ErrorTemplate
+	defer func() { Template, ErrorTemplate = origTemplate, origErrorTemplate }()
+
+	if err := loadTemplates(dir); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Template.ExecuteTemplate(&buf, "index.html", nil); err != nil {
+		t.Fatalf("executing index.html: %v", err)
+	}
+	if got := buf.String(); got != "index-footer-ok" {
+		t.Fatalf("index.html rendered %q, want %q", got, "index-footer-ok")
+	}
+
+	buf.Reset()
+	if err := ErrorTemplate.Execute(&buf, nil); err != nil {
+		t.Fatalf("executing error.html: %v", err)
+	}
+	if got := buf.String(); got != "error-footer-ok" {
+		t.Fatalf("error.html rendered %q, want %q", got, "error-footer-ok")
+	}
+}